@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -10,17 +13,20 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	dbm "github.com/tendermint/tm-db"
 
 	"github.com/tendermint/tendermint/libs/log"
 	tmmath "github.com/tendermint/tendermint/libs/math"
 	tmos "github.com/tendermint/tendermint/libs/os"
 	"github.com/tendermint/tendermint/light"
+	lprovider "github.com/tendermint/tendermint/light/provider"
 	lproxy "github.com/tendermint/tendermint/light/proxy"
 	lrpc "github.com/tendermint/tendermint/light/rpc"
 	dbs "github.com/tendermint/tendermint/light/store/db"
-	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
 	rpcserver "github.com/tendermint/tendermint/rpc/jsonrpc/server"
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
 )
 
 // LightCmd represents the base command when called without any subcommands
@@ -36,22 +42,41 @@ that, it will present the same interface as a full Tendermint node.
 Furthermore to the chainID, a fresh instance of a light client will
 need a primary RPC address, a trusted hash and height and witness RPC addresses
 (if not using sequential verification). To restart the node, thereafter
-only the chainID is required. 
+only the chainID is required.
+
+The primary and witnesses are dialed using the --provider-transport flag
+(http or grpc). Tendermint's gRPC API only exposes broadcast calls, not
+the Status/Commit/Validators calls a light client provider needs, so grpc
+cannot be used for --primary/--witnesses; selecting it fails fast with a
+clear error rather than silently not working.
+
+-p/--primary can be repeated to give failover candidates. The first is
+used as primary; the rest are added as witnesses and are candidates for
+automatic promotion if the primary starts failing, so the proxy does not
+need to be restarted when a single node goes down. Current primary,
+witnesses and rotation history can be queried via the /light_status RPC.
+
+If --metrics-laddr is set, Prometheus metrics are served on /metrics and
+a readiness probe on /health, which reports unhealthy once the latest
+trusted header is older than --health-max-staleness.
 
 `,
 	RunE: runProxy,
 	Args: cobra.ExactArgs(1),
-	Example: `light cosmoshub-3 -p http://52.57.29.196:26657 -w http://public-seed-node.cosmoshub.certus.one:26657
+	Example: `light cosmoshub-3 -p http://52.57.29.196:26657 -p http://public-seed-node.cosmoshub.certus.one:26657
 	--height 962118 --hash 28B97BE9F6DE51AC69F70E0B7BFD7E5C9CD1A595B7DC31AFF27C50D4948020CD`,
 }
 
 var (
 	listenAddr         string
-	primaryAddr        string
+	primaryAddrs       []string
 	witnessAddrsJoined string
+	providerTransport  string
 	chainID            string
 	home               string
 	maxOpenConnections int
+	metricsListenAddr  string
+	healthMaxStaleness time.Duration
 
 	sequential     bool
 	trustingPeriod time.Duration
@@ -65,10 +90,15 @@ var (
 func init() {
 	LightCmd.Flags().StringVar(&listenAddr, "laddr", "tcp://localhost:8888",
 		"Serve the proxy on the given address")
-	LightCmd.Flags().StringVarP(&primaryAddr, "primary", "p", "",
-		"Connect to a Tendermint node at this address")
+	LightCmd.Flags().StringArrayVarP(&primaryAddrs, "primary", "p", nil,
+		"Connect to a Tendermint node at this address. Repeat to give failover candidates: "+
+			"the first is used as primary, the rest are added as witnesses available for automatic "+
+			"promotion if the primary starts failing")
 	LightCmd.Flags().StringVarP(&witnessAddrsJoined, "witnesses", "w", "",
 		"Tendermint nodes to cross-check the primary node, comma-separated")
+	LightCmd.Flags().StringVar(&providerTransport, "provider-transport", string(lprovider.TransportHTTP),
+		"Transport used to dial the primary/witness nodes and the proxy's own upstream client: http or grpc. "+
+			"grpc cannot dial --primary/--witnesses, so selecting it makes the command fail (see --help)")
 	LightCmd.Flags().StringVar(&home, "home-dir", ".tendermint-light", "Specify the home directory")
 	LightCmd.Flags().IntVar(
 		&maxOpenConnections,
@@ -86,6 +116,10 @@ func init() {
 	LightCmd.Flags().BoolVar(&sequential, "sequential", false,
 		"Sequential Verification. Verify all headers sequentially as opposed to using skipping verification",
 	)
+	LightCmd.Flags().StringVar(&metricsListenAddr, "metrics-laddr", "",
+		"Serve Prometheus metrics (/metrics) and a health check (/health) on this address. Disabled if empty")
+	LightCmd.Flags().DurationVar(&healthMaxStaleness, "health-max-staleness", 10*time.Minute,
+		"Maximum age of the latest trusted header before /health reports unhealthy")
 }
 
 func runProxy(cmd *cobra.Command, args []string) error {
@@ -109,6 +143,19 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		witnessesAddrs = []string{}
 	}
 
+	transport, err := lprovider.ParseTransport(providerTransport)
+	if err != nil {
+		return err
+	}
+
+	var primaryAddr string
+	if len(primaryAddrs) > 0 {
+		primaryAddr = primaryAddrs[0]
+		// Extra -p addresses are failover candidates: they cross-check the
+		// primary like any other witness until they're promoted.
+		witnessesAddrs = append(witnessesAddrs, primaryAddrs[1:]...)
+	}
+
 	db, err := dbm.NewGoLevelDB("light-client-db", home)
 	if err != nil {
 		return fmt.Errorf("can't create a db: %w", err)
@@ -116,7 +163,7 @@ func runProxy(cmd *cobra.Command, args []string) error {
 
 	if primaryAddr == "" {
 		var err error
-		primaryAddr, witnessesAddrs, err = checkForExistingProviders(db)
+		transport, primaryAddr, witnessesAddrs, err = checkForExistingProviders(db)
 		if err != nil {
 			return fmt.Errorf("failed to retrieve primary or witness from db. Error: %w", err)
 		}
@@ -125,7 +172,7 @@ func runProxy(cmd *cobra.Command, args []string) error {
 				" Run the command: tendermint light --help for more information")
 		}
 	} else {
-		err := saveProviders(db, primaryAddr, witnessAddrsJoined)
+		err := saveProviders(db, transport, primaryAddr, witnessesAddrs)
 		if err != nil {
 			logger.Error("Unable to save primary and or witness addresses", "err", err)
 		}
@@ -136,32 +183,44 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("can't parse trust level: %w", err)
 	}
 
+	primary, err := lprovider.New(transport, chainID, primaryAddr)
+	if err != nil {
+		return fmt.Errorf("%s provider for %s: %w", transport, primaryAddr, err)
+	}
+	witnesses := make([]lprovider.Provider, len(witnessesAddrs))
+	for i, addr := range witnessesAddrs {
+		witnesses[i], err = lprovider.New(transport, chainID, addr)
+		if err != nil {
+			return fmt.Errorf("%s provider for witness %s: %w", transport, addr, err)
+		}
+	}
+
 	var c *light.Client
 	if trustedHeight > 0 && len(trustedHash) > 0 { // fresh installation
 		if sequential {
-			c, err = light.NewHTTPClient(
+			c, err = light.NewClient(
 				chainID,
 				light.TrustOptions{
 					Period: trustingPeriod,
 					Height: trustedHeight,
 					Hash:   trustedHash,
 				},
-				primaryAddr,
-				witnessesAddrs,
+				primary,
+				witnesses,
 				dbs.New(db, chainID),
 				light.Logger(logger),
 				light.SequentialVerification(),
 			)
 		} else {
-			c, err = light.NewHTTPClient(
+			c, err = light.NewClient(
 				chainID,
 				light.TrustOptions{
 					Period: trustingPeriod,
 					Height: trustedHeight,
 					Hash:   trustedHash,
 				},
-				primaryAddr,
-				witnessesAddrs,
+				primary,
+				witnesses,
 				dbs.New(db, chainID),
 				light.Logger(logger),
 				light.SkippingVerification(trustLevel),
@@ -169,21 +228,21 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		}
 	} else { // continue from latest state
 		if sequential {
-			c, err = light.NewHTTPClientFromTrustedStore(
+			c, err = light.NewClientFromTrustedStore(
 				chainID,
 				trustingPeriod,
-				primaryAddr,
-				witnessesAddrs,
+				primary,
+				witnesses,
 				dbs.New(db, chainID),
 				light.Logger(logger),
 				light.SkippingVerification(trustLevel),
 			)
 		} else {
-			c, err = light.NewHTTPClientFromTrustedStore(
+			c, err = light.NewClientFromTrustedStore(
 				chainID,
 				trustingPeriod,
-				primaryAddr,
-				witnessesAddrs,
+				primary,
+				witnesses,
 				dbs.New(db, chainID),
 				light.Logger(logger),
 				light.SequentialVerification(),
@@ -194,9 +253,34 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	rpcClient, err := rpchttp.New(primaryAddr, "/websocket")
+	rpcClient, err := lprovider.NewRPCClient(transport, primaryAddr)
 	if err != nil {
-		return fmt.Errorf("http client for %s: %w", primaryAddr, err)
+		return fmt.Errorf("%s client for %s: %w", transport, primaryAddr, err)
+	}
+
+	metrics := lproxy.NopMetrics()
+	if metricsListenAddr != "" {
+		mode := "skipping"
+		if sequential {
+			mode = "sequential"
+		}
+		metrics = lproxy.PrometheusMetrics("tendermint_light", mode)
+	}
+
+	candidates := make([]lproxy.Candidate, 0, 1+len(witnessesAddrs))
+	candidates = append(candidates, lproxy.Candidate{Transport: transport, Addr: primaryAddr})
+	for _, addr := range witnessesAddrs {
+		candidates = append(candidates, lproxy.Candidate{Transport: transport, Addr: addr})
+	}
+
+	rotatingClient, err := lproxy.NewRotatingClient(candidates, rpcClient, c, logger,
+		func(primary string, witnesses []string) {
+			if err := saveProviders(db, transport, primary, witnesses); err != nil {
+				logger.Error("Unable to persist rotated providers", "err", err)
+			}
+		}, metrics)
+	if err != nil {
+		return fmt.Errorf("building rotating client: %w", err)
 	}
 
 	cfg := rpcserver.DefaultConfig()
@@ -210,19 +294,59 @@ func runProxy(cmd *cobra.Command, args []string) error {
 		cfg.WriteTimeout = config.RPC.TimeoutBroadcastTxCommit + 1*time.Second
 	}
 
-	p := lproxy.Proxy{
-		Addr:   listenAddr,
-		Config: cfg,
-		Client: lrpc.NewClient(rpcClient, c),
-		Logger: logger,
+	lrpcClient := lrpc.NewClient(rotatingClient, c)
+	routes := lproxy.RPCRoutes(lrpcClient)
+	routes["light_status"] = rpcserver.NewRPCFunc(func(_ *rpctypes.Context) (*lproxy.Status, error) {
+		return rotatingClient.LightStatus(context.Background())
+	}, "")
+
+	mux := http.NewServeMux()
+	rpcserver.RegisterRPCFuncs(mux, routes, logger)
+
+	listener, err := rpcserver.Listen(listenAddr, cfg)
+	if err != nil {
+		return err
 	}
+
+	var metricsListener net.Listener
+	if metricsListenAddr != "" {
+		metricsListener, err = rpcserver.Listen(metricsListenAddr, cfg)
+		if err != nil {
+			return fmt.Errorf("starting metrics listener: %w", err)
+		}
+
+		metricsMux := http.NewServeMux()
+		metricsMux.HandleFunc("/metrics", func(w http.ResponseWriter, req *http.Request) {
+			rotatingClient.UpdateGauges(trustingPeriod)
+			promhttp.Handler().ServeHTTP(w, req)
+		})
+		metricsMux.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
+			if err := rotatingClient.Healthy(healthMaxStaleness); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("OK"))
+		})
+
+		go func() {
+			logger.Info("Starting metrics server...", "laddr", metricsListenAddr)
+			if err := rpcserver.StartHTTPServer(metricsListener, metricsMux, logger, cfg); err != http.ErrServerClosed {
+				logger.Error("metrics server", "err", err)
+			}
+		}()
+	}
+
 	// Stop upon receiving SIGTERM or CTRL-C.
 	tmos.TrapSignal(logger, func() {
-		p.Listener.Close()
+		listener.Close()
+		if metricsListener != nil {
+			metricsListener.Close()
+		}
 	})
 
 	logger.Info("Starting proxy...", "laddr", listenAddr)
-	if err := p.ListenAndServe(); err != http.ErrServerClosed {
+	if err := rpcserver.StartHTTPServer(listener, mux, logger, cfg); err != http.ErrServerClosed {
 		// Error starting or closing listener:
 		logger.Error("proxy ListenAndServe", "err", err)
 	}
@@ -230,27 +354,104 @@ func runProxy(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func checkForExistingProviders(db dbm.DB) (string, []string, error) {
-	primaryBytes, err := db.Get([]byte("p"))
+// providerEntry is one persisted primary or witness address, together with
+// the transport used to dial it, so a restart reconnects with the same
+// dialer the operator originally chose.
+type providerEntry struct {
+	Transport lprovider.Transport `json:"transport"`
+	Addr      string              `json:"addr"`
+}
+
+// providersKey is the DB key used to persist the rotating set of providers
+// as a single JSON list (the primary is always providers[0]), which the
+// proxy rewrites whenever RotatingClient promotes a new primary.
+//
+// legacyPrimaryKey/legacyWitnessKey are the plain, pre-rotation keys this
+// command used to store a single primary address and a comma-joined
+// witness list under. checkForExistingProviders still reads them so an
+// operator upgrading from an older light client doesn't lose a persisted
+// primary/witness set on their first restart.
+var (
+	providersKey     = []byte("providers")
+	legacyPrimaryKey = []byte("p")
+	legacyWitnessKey = []byte("w")
+)
+
+func checkForExistingProviders(db dbm.DB) (lprovider.Transport, string, []string, error) {
+	bz, err := db.Get(providersKey)
 	if err != nil {
-		return "", []string{""}, err
+		return "", "", nil, err
+	}
+	if len(bz) > 0 {
+		var providers []providerEntry
+		if err := json.Unmarshal(bz, &providers); err != nil {
+			return "", "", nil, fmt.Errorf("failed to parse providers db entry: %w", err)
+		}
+		if len(providers) == 0 {
+			return "", "", nil, nil
+		}
+
+		witnessesAddrs := make([]string, len(providers)-1)
+		for i, p := range providers[1:] {
+			witnessesAddrs[i] = p.Addr
+		}
+		return providers[0].Transport, providers[0].Addr, witnessesAddrs, nil
 	}
-	witnessesBytes, err := db.Get([]byte("w"))
+
+	transport, primaryAddr, witnessesAddrs, err := checkForLegacyProviders(db)
 	if err != nil {
-		return "", []string{""}, err
+		return "", "", nil, err
 	}
-	witnessesAddrs := strings.Split(string(witnessesBytes), ",")
-	return string(primaryBytes), witnessesAddrs, nil
+	if primaryAddr == "" {
+		return "", "", nil, nil
+	}
+
+	// Migrate once so subsequent restarts read the new format directly.
+	if err := saveProviders(db, transport, primaryAddr, witnessesAddrs); err != nil {
+		return "", "", nil, fmt.Errorf("failed to migrate legacy providers: %w", err)
+	}
+	return transport, primaryAddr, witnessesAddrs, nil
 }
 
-func saveProviders(db dbm.DB, primaryAddr, witnessesAddrs string) error {
-	err := db.Set([]byte("p"), []byte(primaryAddr))
+// checkForLegacyProviders reads the plain "p"/"w" keys used before
+// providers were persisted as JSON. Those keys predate --provider-transport
+// too, so the transport is always assumed to be http.
+func checkForLegacyProviders(db dbm.DB) (lprovider.Transport, string, []string, error) {
+	primaryBz, err := db.Get(legacyPrimaryKey)
 	if err != nil {
-		return fmt.Errorf("failed to save primary provider: %w", err)
+		return "", "", nil, err
 	}
-	err = db.Set([]byte("w"), []byte(witnessesAddrs))
+	if len(primaryBz) == 0 {
+		return "", "", nil, nil
+	}
+
+	witnessesBz, err := db.Get(legacyWitnessKey)
 	if err != nil {
-		return fmt.Errorf("failed to save witness providers: %w", err)
+		return "", "", nil, err
+	}
+	var witnessesAddrs []string
+	if len(witnessesBz) > 0 {
+		witnessesAddrs = strings.Split(string(witnessesBz), ",")
+	}
+	return lprovider.TransportHTTP, string(primaryBz), witnessesAddrs, nil
+}
+
+func saveProviders(db dbm.DB, transport lprovider.Transport, primaryAddr string, witnessesAddrs []string) error {
+	providers := make([]providerEntry, 0, 1+len(witnessesAddrs))
+	providers = append(providers, providerEntry{Transport: transport, Addr: primaryAddr})
+	for _, addr := range witnessesAddrs {
+		if addr == "" {
+			continue
+		}
+		providers = append(providers, providerEntry{Transport: transport, Addr: addr})
+	}
+
+	bz, err := json.Marshal(providers)
+	if err != nil {
+		return fmt.Errorf("failed to encode providers: %w", err)
+	}
+	if err := db.Set(providersKey, bz); err != nil {
+		return fmt.Errorf("failed to save providers: %w", err)
 	}
 	return nil
 }
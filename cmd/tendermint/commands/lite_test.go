@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	lprovider "github.com/tendermint/tendermint/light/provider"
+)
+
+func TestSaveAndCheckForExistingProviders(t *testing.T) {
+	db := dbm.NewMemDB()
+
+	err := saveProviders(db, lprovider.TransportHTTP, "http://primary", []string{"http://w1", "http://w2"})
+	require.NoError(t, err)
+
+	transport, primary, witnesses, err := checkForExistingProviders(db)
+	require.NoError(t, err)
+	assert.Equal(t, lprovider.TransportHTTP, transport)
+	assert.Equal(t, "http://primary", primary)
+	assert.Equal(t, []string{"http://w1", "http://w2"}, witnesses)
+}
+
+func TestCheckForExistingProvidersEmpty(t *testing.T) {
+	db := dbm.NewMemDB()
+
+	transport, primary, witnesses, err := checkForExistingProviders(db)
+	require.NoError(t, err)
+	assert.Empty(t, transport)
+	assert.Empty(t, primary)
+	assert.Empty(t, witnesses)
+}
+
+func TestCheckForExistingProvidersMigratesLegacyKeys(t *testing.T) {
+	db := dbm.NewMemDB()
+	require.NoError(t, db.Set(legacyPrimaryKey, []byte("http://legacy-primary")))
+	require.NoError(t, db.Set(legacyWitnessKey, []byte("http://legacy-w1,http://legacy-w2")))
+
+	transport, primary, witnesses, err := checkForExistingProviders(db)
+	require.NoError(t, err)
+	assert.Equal(t, lprovider.TransportHTTP, transport)
+	assert.Equal(t, "http://legacy-primary", primary)
+	assert.Equal(t, []string{"http://legacy-w1", "http://legacy-w2"}, witnesses)
+
+	bz, err := db.Get(providersKey)
+	require.NoError(t, err)
+	assert.NotEmpty(t, bz, "legacy keys should have been migrated to the new format")
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"fmt"
+
+	lhttp "github.com/tendermint/tendermint/light/provider/http"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	rpcgrpc "github.com/tendermint/tendermint/rpc/client/grpc"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// Transport identifies the wire protocol used to dial a provider's RPC
+// address. It is used both for the light client's primary/witness
+// providers and for the upstream client the light proxy itself uses to
+// forward unverified calls.
+type Transport string
+
+const (
+	// TransportHTTP dials the provider using plain HTTP, the historical
+	// and default behaviour.
+	TransportHTTP Transport = "http"
+	// TransportGRPC dials Tendermint's existing gRPC broadcast API
+	// directly, for nodes that only expose a gRPC listener. It only
+	// supports broadcast-shaped calls; see NewRPCClient.
+	TransportGRPC Transport = "grpc"
+)
+
+// ParseTransport validates s against the set of supported transports,
+// defaulting to TransportHTTP when s is empty.
+func ParseTransport(s string) (Transport, error) {
+	switch Transport(s) {
+	case "":
+		return TransportHTTP, nil
+	case TransportHTTP, TransportGRPC:
+		return Transport(s), nil
+	default:
+		return "", fmt.Errorf("unknown provider transport %q (want %q or %q)", s, TransportHTTP, TransportGRPC)
+	}
+}
+
+// New dials remote over transport and wraps the resulting RPC client in a
+// light client Provider for chainID.
+//
+// TransportGRPC is rejected here: Tendermint's gRPC API only exposes
+// broadcast calls (see rpc/client/grpc), not the Status/Commit/Validators
+// calls a light client provider needs to fetch and verify headers. grpc is
+// only usable via NewRPCClient.
+func New(transport Transport, chainID, remote string) (Provider, error) {
+	switch transport {
+	case TransportHTTP, "":
+		return lhttp.New(chainID, remote)
+	case TransportGRPC:
+		return nil, fmt.Errorf("provider transport %q does not support the Status/Commit/Validators calls "+
+			"a light client provider needs; use %q for primary/witness addresses", TransportGRPC, TransportHTTP)
+	default:
+		return nil, fmt.Errorf("unknown provider transport %q", transport)
+	}
+}
+
+// NewRPCClient dials remote over transport and returns a general purpose
+// RPC client. It is used by the light proxy, which needs an RPC client of
+// its own to pass into lrpc.NewClient; New above does not use it.
+func NewRPCClient(transport Transport, remote string) (rpcclient.Client, error) {
+	switch transport {
+	case TransportHTTP, "":
+		return rpchttp.New(remote, "/websocket")
+	case TransportGRPC:
+		return rpcgrpc.New(remote)
+	default:
+		return nil, fmt.Errorf("unknown provider transport %q", transport)
+	}
+}
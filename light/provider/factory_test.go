@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTransport(t *testing.T) {
+	transport, err := ParseTransport("")
+	require.NoError(t, err)
+	assert.Equal(t, TransportHTTP, transport)
+
+	transport, err = ParseTransport("http")
+	require.NoError(t, err)
+	assert.Equal(t, TransportHTTP, transport)
+
+	transport, err = ParseTransport("grpc")
+	require.NoError(t, err)
+	assert.Equal(t, TransportGRPC, transport)
+
+	_, err = ParseTransport("websocket")
+	assert.Error(t, err)
+
+	_, err = ParseTransport("bogus")
+	assert.Error(t, err)
+}
+
+func TestNewRejectsGRPC(t *testing.T) {
+	_, err := New(TransportGRPC, "test-chain", "127.0.0.1:9090")
+	assert.Error(t, err)
+}
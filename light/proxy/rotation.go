@@ -0,0 +1,511 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tendermint/tendermint/libs/bytes"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/light"
+	lprovider "github.com/tendermint/tendermint/light/provider"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Candidate is a dialable address RotatingClient can promote to primary: it
+// pairs an address with the transport used to dial it, since providers
+// built over different transports in the same run are allowed.
+type Candidate struct {
+	Transport lprovider.Transport
+	Addr      string
+}
+
+// RotationEvent records one promotion of a witness to primary.
+type RotationEvent struct {
+	At     time.Time `json:"at"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+// Status is returned by the light proxy's /light_status RPC endpoint.
+type Status struct {
+	Primary            string          `json:"primary"`
+	Witnesses          []string        `json:"witnesses"`
+	LastVerifiedHeight int64           `json:"last_verified_height"`
+	Rotations          []RotationEvent `json:"rotations"`
+}
+
+// RotatingClient is the plain RPC client used for unverified passthrough
+// calls (the one handed to lrpc.NewClient). It keeps its own ordered list
+// of candidate addresses - the primary followed by witnesses, in failover
+// order - and redials the next candidate whenever a forwarded call to the
+// current one fails with a connection-level error, so a single node going
+// down doesn't require restarting the process.
+//
+// It does not implement rpcclient.Client by embedding one: every method is
+// written out explicitly below so that every one-shot forwarded call, not
+// just the handful someone remembered to instrument, goes through the same
+// locked-read-and-maybe-rotate path. Subscribe, Unsubscribe and
+// UnsubscribeAll are passed straight through without it.
+type RotatingClient struct {
+	lc     *light.Client
+	logger log.Logger
+
+	mtx        sync.Mutex
+	candidates []Candidate
+	client     rpcclient.Client
+	rotations  []RotationEvent
+	onRotate   func(primary string, witnesses []string)
+	metrics    *Metrics
+}
+
+// NewRotatingClient builds a RotatingClient. candidates must have at least
+// one entry (the primary); client must already be dialed against
+// candidates[0]. onRotate, if non-nil, is called with the new
+// primary/witness set after every rotation so the caller can persist it
+// (e.g. back to the providers DB). metrics may be NopMetrics() if metrics
+// collection is disabled.
+func NewRotatingClient(
+	candidates []Candidate,
+	client rpcclient.Client,
+	lc *light.Client,
+	logger log.Logger,
+	onRotate func(primary string, witnesses []string),
+	metrics *Metrics,
+) (*RotatingClient, error) {
+	if len(candidates) == 0 {
+		return nil, errors.New("at least one candidate (the primary) is required")
+	}
+	return &RotatingClient{
+		lc:         lc,
+		logger:     logger,
+		candidates: candidates,
+		client:     client,
+		onRotate:   onRotate,
+		metrics:    metrics,
+	}, nil
+}
+
+// current returns the client currently in use, under lock.
+func (r *RotatingClient) current() rpcclient.Client {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.client
+}
+
+// currentCandidate returns the client currently in use together with the
+// candidate it was dialed against, atomically, so a caller that later
+// observes that client failing can tell rotate() exactly which candidate it
+// saw fail.
+func (r *RotatingClient) currentCandidate() (rpcclient.Client, Candidate) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.client, r.candidates[0]
+}
+
+// rotate demotes failed - the candidate the caller actually observed fail -
+// behind the rest of the candidates and promotes the next one, redialing
+// the upstream RPC connection to match. If failed is no longer
+// r.candidates[0], someone else already rotated past it for this same
+// outage, so rotate no-ops instead of blindly promoting again: without
+// this check, N concurrent requests failing against the same dead primary
+// would trigger N rotations and could cycle the list all the way back
+// around, leaving the original failing node persisted as the "new" primary.
+func (r *RotatingClient) rotate(failed Candidate, reason string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.candidates[0] != failed {
+		return
+	}
+
+	if len(r.candidates) < 2 {
+		r.logger.Error("light proxy: primary is failing but no witness is available to promote", "reason", reason)
+		return
+	}
+
+	next := r.candidates[1]
+	client, err := lprovider.NewRPCClient(next.Transport, next.Addr)
+	if err != nil {
+		r.logger.Error("light proxy: failed to reconnect to promoted witness", "addr", next.Addr, "err", err)
+		return
+	}
+
+	r.logger.Info("light proxy: promoting witness to primary", "from", failed.Addr, "to", next.Addr, "reason", reason)
+	rotated := append([]Candidate{}, r.candidates[1:]...)
+	rotated = append(rotated, failed)
+	r.candidates = rotated
+	r.client = client
+	r.rotations = append(r.rotations, RotationEvent{
+		At:     time.Now(),
+		From:   failed.Addr,
+		To:     next.Addr,
+		Reason: reason,
+	})
+	r.metrics.PrimaryRotations.Add(1)
+
+	if r.onRotate != nil {
+		witnesses := make([]string, len(r.candidates)-1)
+		for i, c := range r.candidates[1:] {
+			witnesses[i] = c.Addr
+		}
+		r.onRotate(r.candidates[0].Addr, witnesses)
+	}
+}
+
+// isConnError reports whether err looks like a connection-level failure
+// (as opposed to e.g. an application error from the node), the kind of
+// failure that warrants failing over to the next candidate.
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range []string{"connection refused", "connection reset", "EOF", "no such host", "broken pipe", "i/o timeout"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// call runs fn against the current client, records its latency under
+// method's label, and rotates away from the candidate it observed if fn
+// returned a connection-level error.
+func (r *RotatingClient) call(method string, fn func(c rpcclient.Client) error) error {
+	client, candidate := r.currentCandidate()
+
+	start := time.Now()
+	err := fn(client)
+	r.metrics.RPCMethodLatency.With("method", method).Observe(time.Since(start).Seconds())
+	if isConnError(err) {
+		r.rotate(candidate, fmt.Sprintf("%s: %v", method, err))
+	}
+	return err
+}
+
+// LightStatus reports the light proxy's current primary, witnesses, last
+// verified height and rotation history.
+func (r *RotatingClient) LightStatus(_ context.Context) (*Status, error) {
+	height, err := r.lc.LastTrustedHeight()
+	if err != nil {
+		return nil, fmt.Errorf("last trusted height: %w", err)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	witnesses := make([]string, len(r.candidates)-1)
+	for i, c := range r.candidates[1:] {
+		witnesses[i] = c.Addr
+	}
+	rotations := make([]RotationEvent, len(r.rotations))
+	copy(rotations, r.rotations)
+
+	return &Status{
+		Primary:            r.candidates[0].Addr,
+		Witnesses:          witnesses,
+		LastVerifiedHeight: height,
+		Rotations:          rotations,
+	}, nil
+}
+
+// UpdateGauges refreshes the trusted-period-remaining and
+// latest-trusted-height gauges from the current state of lc. It is called
+// whenever /metrics is scraped.
+func (r *RotatingClient) UpdateGauges(trustingPeriod time.Duration) {
+	height, err := r.lc.LastTrustedHeight()
+	if err != nil || height <= 0 {
+		return
+	}
+	r.metrics.LatestTrustedHeight.Set(float64(height))
+
+	lb, err := r.lc.TrustedLightBlock(height)
+	if err != nil {
+		return
+	}
+	remaining := trustingPeriod - time.Since(lb.Time)
+	r.metrics.TrustedPeriodRemaining.Set(remaining.Seconds())
+}
+
+// Healthy returns an error if the light client has no trusted header, or if
+// the block time of its latest trusted header is more than maxStaleness
+// ago, i.e. it is too far behind to be trusted by a caller behind a load
+// balancer or Kubernetes readiness probe.
+func (r *RotatingClient) Healthy(maxStaleness time.Duration) error {
+	height, err := r.lc.LastTrustedHeight()
+	if err != nil {
+		return fmt.Errorf("last trusted height: %w", err)
+	}
+	if height <= 0 {
+		return errors.New("no trusted header yet")
+	}
+
+	lb, err := r.lc.TrustedLightBlock(height)
+	if err != nil {
+		return fmt.Errorf("trusted light block at height %d: %w", height, err)
+	}
+
+	if age := time.Since(lb.Time); age > maxStaleness {
+		return fmt.Errorf("latest trusted header is %s old, exceeding max staleness %s", age, maxStaleness)
+	}
+	return nil
+}
+
+// The methods below are rpcclient.Client, written out explicitly (rather
+// than promoted from an embedded field) so rotation and latency tracking
+// apply uniformly to every forwarded call.
+
+func (r *RotatingClient) ABCIInfo(ctx context.Context) (result *ctypes.ResultABCIInfo, err error) {
+	err = r.call("abci_info", func(c rpcclient.Client) error {
+		result, err = c.ABCIInfo(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) ABCIQuery(
+	ctx context.Context, path string, data bytes.HexBytes,
+) (result *ctypes.ResultABCIQuery, err error) {
+	err = r.call("abci_query", func(c rpcclient.Client) error {
+		result, err = c.ABCIQuery(ctx, path, data)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) ABCIQueryWithOptions(
+	ctx context.Context, path string, data bytes.HexBytes, opts rpcclient.ABCIQueryOptions,
+) (result *ctypes.ResultABCIQuery, err error) {
+	err = r.call("abci_query", func(c rpcclient.Client) error {
+		result, err = c.ABCIQueryWithOptions(ctx, path, data, opts)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BroadcastTxCommit(
+	ctx context.Context, tx types.Tx,
+) (result *ctypes.ResultBroadcastTxCommit, err error) {
+	err = r.call("broadcast_tx_commit", func(c rpcclient.Client) error {
+		result, err = c.BroadcastTxCommit(ctx, tx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BroadcastTxAsync(ctx context.Context, tx types.Tx) (result *ctypes.ResultBroadcastTx, err error) {
+	err = r.call("broadcast_tx_async", func(c rpcclient.Client) error {
+		result, err = c.BroadcastTxAsync(ctx, tx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BroadcastTxSync(ctx context.Context, tx types.Tx) (result *ctypes.ResultBroadcastTx, err error) {
+	err = r.call("broadcast_tx_sync", func(c rpcclient.Client) error {
+		result, err = c.BroadcastTxSync(ctx, tx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Block(ctx context.Context, height *int64) (result *ctypes.ResultBlock, err error) {
+	err = r.call("block", func(c rpcclient.Client) error {
+		result, err = c.Block(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BlockByHash(ctx context.Context, hash []byte) (result *ctypes.ResultBlock, err error) {
+	err = r.call("block_by_hash", func(c rpcclient.Client) error {
+		result, err = c.BlockByHash(ctx, hash)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BlockResults(ctx context.Context, height *int64) (result *ctypes.ResultBlockResults, err error) {
+	err = r.call("block_results", func(c rpcclient.Client) error {
+		result, err = c.BlockResults(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Commit(ctx context.Context, height *int64) (result *ctypes.ResultCommit, err error) {
+	err = r.call("commit", func(c rpcclient.Client) error {
+		result, err = c.Commit(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Validators(
+	ctx context.Context, height *int64, page, perPage *int,
+) (result *ctypes.ResultValidators, err error) {
+	err = r.call("validators", func(c rpcclient.Client) error {
+		result, err = c.Validators(ctx, height, page, perPage)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Tx(ctx context.Context, hash []byte, prove bool) (result *ctypes.ResultTx, err error) {
+	err = r.call("tx", func(c rpcclient.Client) error {
+		result, err = c.Tx(ctx, hash, prove)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) TxSearch(
+	ctx context.Context, query string, prove bool, page, perPage *int, orderBy string,
+) (result *ctypes.ResultTxSearch, err error) {
+	err = r.call("tx_search", func(c rpcclient.Client) error {
+		result, err = c.TxSearch(ctx, query, prove, page, perPage, orderBy)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Genesis(ctx context.Context) (result *ctypes.ResultGenesis, err error) {
+	err = r.call("genesis", func(c rpcclient.Client) error {
+		result, err = c.Genesis(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BlockchainInfo(
+	ctx context.Context, minHeight, maxHeight int64,
+) (result *ctypes.ResultBlockchainInfo, err error) {
+	err = r.call("blockchain", func(c rpcclient.Client) error {
+		result, err = c.BlockchainInfo(ctx, minHeight, maxHeight)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Status(ctx context.Context) (result *ctypes.ResultStatus, err error) {
+	err = r.call("status", func(c rpcclient.Client) error {
+		result, err = c.Status(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) NetInfo(ctx context.Context) (result *ctypes.ResultNetInfo, err error) {
+	err = r.call("net_info", func(c rpcclient.Client) error {
+		result, err = c.NetInfo(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) DumpConsensusState(ctx context.Context) (result *ctypes.ResultDumpConsensusState, err error) {
+	err = r.call("dump_consensus_state", func(c rpcclient.Client) error {
+		result, err = c.DumpConsensusState(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) ConsensusState(ctx context.Context) (result *ctypes.ResultConsensusState, err error) {
+	err = r.call("consensus_state", func(c rpcclient.Client) error {
+		result, err = c.ConsensusState(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) ConsensusParams(ctx context.Context, height *int64) (result *ctypes.ResultConsensusParams, err error) {
+	err = r.call("consensus_params", func(c rpcclient.Client) error {
+		result, err = c.ConsensusParams(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) Health(ctx context.Context) (result *ctypes.ResultHealth, err error) {
+	err = r.call("health", func(c rpcclient.Client) error {
+		result, err = c.Health(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) UnconfirmedTxs(ctx context.Context, limit *int) (result *ctypes.ResultUnconfirmedTxs, err error) {
+	err = r.call("unconfirmed_txs", func(c rpcclient.Client) error {
+		result, err = c.UnconfirmedTxs(ctx, limit)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) NumUnconfirmedTxs(ctx context.Context) (result *ctypes.ResultUnconfirmedTxs, err error) {
+	err = r.call("num_unconfirmed_txs", func(c rpcclient.Client) error {
+		result, err = c.NumUnconfirmedTxs(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) CheckTx(ctx context.Context, tx types.Tx) (result *ctypes.ResultCheckTx, err error) {
+	err = r.call("check_tx", func(c rpcclient.Client) error {
+		result, err = c.CheckTx(ctx, tx)
+		return err
+	})
+	return result, err
+}
+
+func (r *RotatingClient) BroadcastEvidence(
+	ctx context.Context, ev types.Evidence,
+) (result *ctypes.ResultBroadcastEvidence, err error) {
+	err = r.call("broadcast_evidence", func(c rpcclient.Client) error {
+		result, err = c.BroadcastEvidence(ctx, ev)
+		return err
+	})
+	return result, err
+}
+
+// Subscribe, Unsubscribe and UnsubscribeAll are long-lived/streaming calls
+// rather than one-shot requests, so they're passed straight through to the
+// current client without the rotate-on-error wrapping above.
+func (r *RotatingClient) Subscribe(
+	ctx context.Context, subscriber, query string, outCapacity ...int,
+) (<-chan ctypes.ResultEvent, error) {
+	return r.current().Subscribe(ctx, subscriber, query, outCapacity...)
+}
+
+func (r *RotatingClient) Unsubscribe(ctx context.Context, subscriber, query string) error {
+	return r.current().Unsubscribe(ctx, subscriber, query)
+}
+
+func (r *RotatingClient) UnsubscribeAll(ctx context.Context, subscriber string) error {
+	return r.current().UnsubscribeAll(ctx, subscriber)
+}
+
+func (r *RotatingClient) Start() error                { return r.current().Start() }
+func (r *RotatingClient) Stop() error                 { return r.current().Stop() }
+func (r *RotatingClient) IsRunning() bool             { return r.current().IsRunning() }
+func (r *RotatingClient) Quit() <-chan struct{}       { return r.current().Quit() }
+func (r *RotatingClient) String() string              { return "RotatingClient{" + r.current().String() + "}" }
+func (r *RotatingClient) SetLogger(logger log.Logger) { r.current().SetLogger(logger) }
+func (r *RotatingClient) Reset() error                { return r.current().Reset() }
+
+var _ rpcclient.Client = (*RotatingClient)(nil)
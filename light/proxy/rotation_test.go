@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/libs/log"
+	lprovider "github.com/tendermint/tendermint/light/provider"
+)
+
+func TestIsConnError(t *testing.T) {
+	assert.True(t, isConnError(errors.New("dial tcp 1.2.3.4:26657: connection refused")))
+	assert.True(t, isConnError(context.DeadlineExceeded))
+	assert.False(t, isConnError(errors.New("abci: invalid tx")))
+	assert.False(t, isConnError(nil))
+}
+
+func TestRotatingClientRotate(t *testing.T) {
+	candidates := []Candidate{
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:11111"},
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:22222"},
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:33333"},
+	}
+	initial, err := lprovider.NewRPCClient(candidates[0].Transport, candidates[0].Addr)
+	require.NoError(t, err)
+
+	var gotPrimary string
+	var gotWitnesses []string
+	rc, err := NewRotatingClient(candidates, initial, nil, log.NewNopLogger(),
+		func(primary string, witnesses []string) {
+			gotPrimary = primary
+			gotWitnesses = witnesses
+		}, NopMetrics())
+	require.NoError(t, err)
+
+	rc.rotate(candidates[0], "test failure")
+
+	assert.Equal(t, "http://127.0.0.1:22222", gotPrimary)
+	assert.Equal(t, []string{"http://127.0.0.1:33333", "http://127.0.0.1:11111"}, gotWitnesses)
+	require.Len(t, rc.rotations, 1)
+	assert.Equal(t, "http://127.0.0.1:11111", rc.rotations[0].From)
+	assert.Equal(t, "http://127.0.0.1:22222", rc.rotations[0].To)
+}
+
+func TestNewRotatingClientRequiresACandidate(t *testing.T) {
+	_, err := NewRotatingClient(nil, nil, nil, log.NewNopLogger(), nil, NopMetrics())
+	assert.Error(t, err)
+}
+
+// TestRotatingClientRotateConcurrentFailuresRotateOnce simulates several
+// in-flight requests discovering the same dead primary at once, as happens
+// during a real outage. Only the first rotate() call for a given failed
+// candidate should take effect; the rest must no-op instead of blindly
+// promoting past the new primary too, which would cycle the whole list and
+// could leave the original failing node as "primary" again.
+func TestRotatingClientRotateConcurrentFailuresRotateOnce(t *testing.T) {
+	candidates := []Candidate{
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:11111"},
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:22222"},
+		{Transport: lprovider.TransportHTTP, Addr: "http://127.0.0.1:33333"},
+	}
+	initial, err := lprovider.NewRPCClient(candidates[0].Transport, candidates[0].Addr)
+	require.NoError(t, err)
+
+	rc, err := NewRotatingClient(candidates, initial, nil, log.NewNopLogger(), nil, NopMetrics())
+	require.NoError(t, err)
+
+	const concurrentFailures = 8
+	failed := candidates[0]
+
+	var wg sync.WaitGroup
+	wg.Add(concurrentFailures)
+	for i := 0; i < concurrentFailures; i++ {
+		go func() {
+			defer wg.Done()
+			rc.rotate(failed, "concurrent test failure")
+		}()
+	}
+	wg.Wait()
+
+	// Exactly one rotation should have happened: the new primary is the
+	// witness that was actually promoted, not something further down the
+	// list, and the originally failing node is demoted rather than ending
+	// up back on top.
+	require.Len(t, rc.rotations, 1)
+	assert.Equal(t, "http://127.0.0.1:22222", rc.candidates[0].Addr)
+	assert.Equal(t, []string{"http://127.0.0.1:33333", "http://127.0.0.1:11111"},
+		[]string{rc.candidates[1].Addr, rc.candidates[2].Addr})
+}
@@ -0,0 +1,214 @@
+// Package grpc lets a Tendermint RPC client talk to a node over gRPC. It is
+// backed by Tendermint's existing gRPC broadcast API (rpc/grpc), which only
+// exposes Ping and BroadcastTx: there is no gRPC equivalent of Status,
+// Commit, Validators or ABCIQuery. Client therefore only implements the
+// broadcast-shaped methods of rpcclient.Client, and Health (via Ping), for
+// real; every other RPC method returns ErrUnsupported rather than silently
+// failing or panicking. The service methods (Start, Stop, Reset, ...) are
+// no-ops.
+package grpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tendermint/tendermint/libs/bytes"
+	"github.com/tendermint/tendermint/libs/log"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	coregrpc "github.com/tendermint/tendermint/rpc/grpc"
+	"github.com/tendermint/tendermint/types"
+)
+
+// ErrUnsupported is returned by every Client method that Tendermint's gRPC
+// broadcast API has no equivalent for.
+var ErrUnsupported = fmt.Errorf("not supported over the grpc provider transport")
+
+// Client is a rpcclient.Client backed by Tendermint's gRPC broadcast API.
+type Client struct {
+	broadcast coregrpc.BroadcastAPIClient
+}
+
+// New dials remote (a gRPC listener serving Tendermint's broadcast API) and
+// verifies the connection with a Ping before returning.
+func New(remote string) (*Client, error) {
+	broadcast := coregrpc.StartGRPCClient(remote)
+	if _, err := broadcast.Ping(context.Background(), &coregrpc.RequestPing{}); err != nil {
+		return nil, fmt.Errorf("pinging grpc broadcast API at %s: %w", remote, err)
+	}
+	return &Client{broadcast: broadcast}, nil
+}
+
+func unsupported(method string) error {
+	return fmt.Errorf("%s: %w", method, ErrUnsupported)
+}
+
+func (c *Client) broadcastTx(ctx context.Context, tx types.Tx) (*coregrpc.ResponseBroadcastTx, error) {
+	res, err := c.broadcast.BroadcastTx(ctx, &coregrpc.RequestBroadcastTx{Tx: tx})
+	if err != nil {
+		return nil, fmt.Errorf("broadcast_tx over grpc: %w", err)
+	}
+	return res, nil
+}
+
+// BroadcastTxSync broadcasts tx via the gRPC broadcast API and returns its
+// CheckTx result.
+func (c *Client) BroadcastTxSync(ctx context.Context, tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	res, err := c.broadcastTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	result := &ctypes.ResultBroadcastTx{Hash: tx.Hash()}
+	if res.CheckTx != nil {
+		result.Code = res.CheckTx.Code
+		result.Data = res.CheckTx.Data
+		result.Log = res.CheckTx.Log
+		result.Codespace = res.CheckTx.Codespace
+	}
+	return result, nil
+}
+
+// BroadcastTxAsync has the same behaviour as BroadcastTxSync here: the gRPC
+// broadcast API has no fire-and-forget mode, so this still waits for
+// CheckTx to come back.
+func (c *Client) BroadcastTxAsync(ctx context.Context, tx types.Tx) (*ctypes.ResultBroadcastTx, error) {
+	return c.BroadcastTxSync(ctx, tx)
+}
+
+// BroadcastTxCommit broadcasts tx and folds in whatever DeliverTx result the
+// gRPC broadcast API returned. Unlike the HTTP BroadcastTxCommit, Height is
+// always 0: the broadcast API doesn't confirm which block included the tx.
+func (c *Client) BroadcastTxCommit(ctx context.Context, tx types.Tx) (*ctypes.ResultBroadcastTxCommit, error) {
+	res, err := c.broadcastTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	result := &ctypes.ResultBroadcastTxCommit{Hash: tx.Hash()}
+	if res.CheckTx != nil {
+		result.CheckTx = *res.CheckTx
+	}
+	if res.DeliverTx != nil {
+		result.DeliverTx = *res.DeliverTx
+	}
+	return result, nil
+}
+
+// Health pings the gRPC broadcast API.
+func (c *Client) Health(ctx context.Context) (*ctypes.ResultHealth, error) {
+	if _, err := c.broadcast.Ping(ctx, &coregrpc.RequestPing{}); err != nil {
+		return nil, fmt.Errorf("ping over grpc: %w", err)
+	}
+	return &ctypes.ResultHealth{}, nil
+}
+
+func (c *Client) ABCIInfo(context.Context) (*ctypes.ResultABCIInfo, error) {
+	return nil, unsupported("abci_info")
+}
+
+func (c *Client) ABCIQuery(context.Context, string, bytes.HexBytes) (*ctypes.ResultABCIQuery, error) {
+	return nil, unsupported("abci_query")
+}
+
+func (c *Client) ABCIQueryWithOptions(
+	context.Context, string, bytes.HexBytes, rpcclient.ABCIQueryOptions,
+) (*ctypes.ResultABCIQuery, error) {
+	return nil, unsupported("abci_query")
+}
+
+func (c *Client) CheckTx(context.Context, types.Tx) (*ctypes.ResultCheckTx, error) {
+	return nil, unsupported("check_tx")
+}
+
+func (c *Client) Block(context.Context, *int64) (*ctypes.ResultBlock, error) {
+	return nil, unsupported("block")
+}
+
+func (c *Client) BlockByHash(context.Context, []byte) (*ctypes.ResultBlock, error) {
+	return nil, unsupported("block_by_hash")
+}
+
+func (c *Client) BlockResults(context.Context, *int64) (*ctypes.ResultBlockResults, error) {
+	return nil, unsupported("block_results")
+}
+
+func (c *Client) Commit(context.Context, *int64) (*ctypes.ResultCommit, error) {
+	return nil, unsupported("commit")
+}
+
+func (c *Client) Validators(context.Context, *int64, *int, *int) (*ctypes.ResultValidators, error) {
+	return nil, unsupported("validators")
+}
+
+func (c *Client) Tx(context.Context, []byte, bool) (*ctypes.ResultTx, error) {
+	return nil, unsupported("tx")
+}
+
+func (c *Client) TxSearch(
+	context.Context, string, bool, *int, *int, string,
+) (*ctypes.ResultTxSearch, error) {
+	return nil, unsupported("tx_search")
+}
+
+func (c *Client) Genesis(context.Context) (*ctypes.ResultGenesis, error) {
+	return nil, unsupported("genesis")
+}
+
+func (c *Client) BlockchainInfo(context.Context, int64, int64) (*ctypes.ResultBlockchainInfo, error) {
+	return nil, unsupported("blockchain")
+}
+
+func (c *Client) Status(context.Context) (*ctypes.ResultStatus, error) {
+	return nil, unsupported("status")
+}
+
+func (c *Client) NetInfo(context.Context) (*ctypes.ResultNetInfo, error) {
+	return nil, unsupported("net_info")
+}
+
+func (c *Client) DumpConsensusState(context.Context) (*ctypes.ResultDumpConsensusState, error) {
+	return nil, unsupported("dump_consensus_state")
+}
+
+func (c *Client) ConsensusState(context.Context) (*ctypes.ResultConsensusState, error) {
+	return nil, unsupported("consensus_state")
+}
+
+func (c *Client) ConsensusParams(context.Context, *int64) (*ctypes.ResultConsensusParams, error) {
+	return nil, unsupported("consensus_params")
+}
+
+func (c *Client) UnconfirmedTxs(context.Context, *int) (*ctypes.ResultUnconfirmedTxs, error) {
+	return nil, unsupported("unconfirmed_txs")
+}
+
+func (c *Client) NumUnconfirmedTxs(context.Context) (*ctypes.ResultUnconfirmedTxs, error) {
+	return nil, unsupported("num_unconfirmed_txs")
+}
+
+func (c *Client) BroadcastEvidence(context.Context, types.Evidence) (*ctypes.ResultBroadcastEvidence, error) {
+	return nil, unsupported("broadcast_evidence")
+}
+
+func (c *Client) Subscribe(
+	context.Context, string, string, ...int,
+) (<-chan ctypes.ResultEvent, error) {
+	return nil, unsupported("subscribe")
+}
+
+func (c *Client) Unsubscribe(context.Context, string, string) error {
+	return unsupported("unsubscribe")
+}
+
+func (c *Client) UnsubscribeAll(context.Context, string) error {
+	return unsupported("unsubscribe_all")
+}
+
+func (c *Client) Start() error          { return nil }
+func (c *Client) Stop() error           { return nil }
+func (c *Client) IsRunning() bool       { return true }
+func (c *Client) Quit() <-chan struct{} { return nil }
+func (c *Client) String() string        { return "grpc broadcast client" }
+func (c *Client) SetLogger(log.Logger)  {}
+func (c *Client) Reset() error          { return nil }
+
+var _ rpcclient.Client = (*Client)(nil)